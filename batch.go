@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/chrissnell/wxinflux/config"
+	"github.com/chrissnell/wxinflux/wx"
+)
+
+const (
+	defaultQueueSize          = 1000
+	defaultQueueBatchSize     = 100
+	defaultQueueFlushInterval = time.Second
+	batchWriteInitialBackoff  = 500 * time.Millisecond
+	batchWriteMaxBackoff      = 30 * time.Second
+	batchWriteMaxAttempts     = 5
+)
+
+// batchedOutput sits between storeReports and a synchronous Outputs
+// backend.  It buffers incoming reports on a bounded channel, groups up
+// to BatchSize of them (or whatever has accumulated after
+// FlushIntervalMS) into a single inner.Write call, and retries a failed
+// write with exponential backoff.  If the queue is ever full, the oldest
+// queued report is dropped to make room and droppedCount is incremented,
+// so a stalled backend can never block readReports.
+type batchedOutput struct {
+	inner Outputs
+
+	queue         chan wx.WxReport
+	batchSize     int
+	flushInterval time.Duration
+
+	droppedCount uint64
+
+	done chan struct{}
+	stop chan struct{}
+}
+
+func newBatchedOutput(inner Outputs, cfg config.OutputQueueConfig) *batchedOutput {
+	size := cfg.Size
+	if size <= 0 {
+		size = defaultQueueSize
+	}
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultQueueBatchSize
+	}
+	flushInterval := defaultQueueFlushInterval
+	if cfg.FlushIntervalMS > 0 {
+		flushInterval = time.Duration(cfg.FlushIntervalMS) * time.Millisecond
+	}
+
+	b := &batchedOutput{
+		inner:         inner,
+		queue:         make(chan wx.WxReport, size),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		done:          make(chan struct{}),
+		stop:          make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+// Write enqueues reports for the background batcher.  It never blocks:
+// if the queue is full, the oldest queued report is dropped to make room.
+func (b *batchedOutput) Write(ctx context.Context, reports []wx.WxReport) error {
+	for _, report := range reports {
+		select {
+		case b.queue <- report:
+		default:
+			select {
+			case <-b.queue:
+			default:
+			}
+			select {
+			case b.queue <- report:
+			default:
+			}
+			dropped := atomic.AddUint64(&b.droppedCount, 1)
+			log.Printf("Output queue full; dropped oldest report (transmitter %d). Total dropped: %d", report.TransmitterID, dropped)
+		}
+	}
+	return nil
+}
+
+func (b *batchedOutput) run() {
+	defer close(b.done)
+
+	ticker := time.NewTicker(b.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]wx.WxReport, 0, b.batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		b.flushWithRetry(batch)
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case report := <-b.queue:
+			batch = append(batch, report)
+			if len(batch) >= b.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-b.stop:
+			flush()
+			return
+		}
+	}
+}
+
+// flushWithRetry writes batch to the inner backend, retrying with
+// exponential backoff on error.  If every attempt fails, the batch is
+// dropped and droppedCount is incremented so the failure is observable.
+func (b *batchedOutput) flushWithRetry(batch []wx.WxReport) {
+	toWrite := make([]wx.WxReport, len(batch))
+	copy(toWrite, batch)
+
+	backoff := batchWriteInitialBackoff
+	for attempt := 1; attempt <= batchWriteMaxAttempts; attempt++ {
+		if err := b.inner.Write(context.Background(), toWrite); err == nil {
+			return
+		} else if attempt == batchWriteMaxAttempts {
+			log.Println("Giving up on batch after", attempt, "attempts:", err)
+			atomic.AddUint64(&b.droppedCount, uint64(len(toWrite)))
+			return
+		} else {
+			log.Println("Error writing batch to output backend, retrying:", err)
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > batchWriteMaxBackoff {
+			backoff = batchWriteMaxBackoff
+		}
+	}
+}
+
+func (b *batchedOutput) Close() {
+	close(b.stop)
+	<-b.done
+	b.inner.Close()
+}
@@ -8,11 +8,43 @@ import (
 
 // Config is the main configuration object
 type Config struct {
-	InfluxDB InfluxDBConfig `yaml:"influxdb"`
-	Si2000   Si2000Config   `yaml:"si2000"`
+	// Output selects which backend(s) storeReports writes to: "v1"
+	// (default) for the classic InfluxDB 1.x client, or "v2" for
+	// InfluxDB 2.x/Flux via InfluxDBv2.
+	Output string `yaml:"output"`
+
+	// StationElevationM is the station's elevation above sea level, in
+	// meters, used to reduce station pressure to sea-level pressure.  The
+	// ET0 calculation does not use it; it assumes sea-level pressure.
+	StationElevationM float64 `yaml:"station_elevation_m"`
+
+	InfluxDB   InfluxDBConfig    `yaml:"influxdb"`
+	InfluxDBv2 InfluxDBv2Config  `yaml:"influxdb_v2"`
+	Si2000     Si2000Config      `yaml:"si2000"`
+	MQTT       MQTTConfig        `yaml:"mqtt"`
+	HTTP       HTTPConfig        `yaml:"http"`
+	Queue      OutputQueueConfig `yaml:"output_queue"`
+
+	// Stations maps transmitter ID to a human-readable station name,
+	// written as the "station_name" tag alongside the numeric
+	// transmitter-id tag.
+	Stations map[uint8]string `yaml:"stations"`
+}
+
+// OutputQueueConfig bounds the outbound queue that sits between
+// storeReports and a (potentially slow) output backend, so a backend
+// hiccup never blocks readReports.
+type OutputQueueConfig struct {
+	// Size is the maximum number of reports buffered before the oldest
+	// queued report is dropped to make room for a new one.
+	Size int `yaml:"size"`
+	// BatchSize is the maximum number of reports written in one call.
+	BatchSize int `yaml:"batch_size"`
+	// FlushIntervalMS flushes a partial batch after this many milliseconds.
+	FlushIntervalMS int `yaml:"flush_interval_ms"`
 }
 
-// InfluxDBConfig holds InfluxDB-specific configuration
+// InfluxDBConfig holds InfluxDB 1.x-specific configuration
 type InfluxDBConfig struct {
 	InfluxURL    string `yaml:"url"`
 	InfluxDBName string `yaml:"dbname"`
@@ -20,12 +52,66 @@ type InfluxDBConfig struct {
 	InfluxPass   string `yaml:"pass"`
 }
 
+// InfluxDBv2Config holds InfluxDB 2.x/Flux-specific configuration
+type InfluxDBv2Config struct {
+	URL    string `yaml:"url"`
+	Org    string `yaml:"org"`
+	Bucket string `yaml:"bucket"`
+	Token  string `yaml:"token"`
+
+	// BatchSize is the number of points buffered before a batch write.
+	BatchSize uint `yaml:"batch_size"`
+	// FlushIntervalMS flushes a partial batch after this many milliseconds.
+	FlushIntervalMS uint `yaml:"flush_interval_ms"`
+}
+
 // Si2000Config holds Si2000-specific configuration
 type Si2000Config struct {
 	Device string `yaml:"device"`
 	Baud   uint16 `yaml:"baud"`
 }
 
+// MQTTConfig holds the configuration for ingesting WxPackets from, and
+// publishing WxReports to, an MQTT broker.  It is optional; leave
+// Enabled false (the default) to run with the local Si1000 serial
+// device only.
+type MQTTConfig struct {
+	Enabled bool `yaml:"enabled"`
+
+	// BrokerURL is a full MQTT URL, e.g. "tcp://broker.example.com:1883"
+	// or "tls://broker.example.com:8883".
+	BrokerURL string `yaml:"broker_url"`
+	ClientID  string `yaml:"client_id"`
+
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+
+	TLS           bool   `yaml:"tls"`
+	TLSSkipVerify bool   `yaml:"tls_skip_verify"`
+	CAFile        string `yaml:"ca_file"`
+
+	// SubscribeTopic is where raw WxPacket JSON frames are read from.
+	SubscribeTopic string `yaml:"subscribe_topic"`
+
+	// PublishTopicPrefix is prepended to "/<transmitter-id>" when
+	// republishing derived WxReports.
+	PublishTopicPrefix string `yaml:"publish_topic_prefix"`
+
+	QoS           byte   `yaml:"qos"`
+	KeepAliveSecs uint16 `yaml:"keepalive_secs"`
+}
+
+// HTTPConfig holds the configuration for the read-only HTTP REST API
+// exposing current conditions and recent history.
+type HTTPConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Address string `yaml:"address"`
+
+	// BufferSize is how many recent reports, across all transmitters,
+	// the in-memory history ring buffer retains.
+	BufferSize int `yaml:"buffer_size"`
+}
+
 // New creates an new config object from the given filename.
 func New(filename string) (Config, error) {
 	cfgFile, err := ioutil.ReadFile(filename)
@@ -0,0 +1,199 @@
+// Package httpapi exposes current conditions and recent history over a
+// small read-only REST API, backed by an in-memory ring buffer of recent
+// WxReports plus the connection status of each configured report source.
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/chrissnell/wxinflux/config"
+	"github.com/chrissnell/wxinflux/wx"
+)
+
+// entry wraps a report for the ring buffer.  "since" filtering uses
+// Report.Timestamp (when the source decoded the packet), not when the
+// HTTP API happened to ingest it.  The buffer itself is kept, and
+// returned, in the order reports were ingested; it is not re-sorted by
+// Timestamp.
+type entry struct {
+	Report wx.WxReport `json:"report"`
+}
+
+// StatusFunc reports the current connection status of one configured
+// source, for use by the /healthz endpoint.
+type StatusFunc func() wx.ConnStatus
+
+// Server serves the REST API over HTTP.  It is fed reports via Ingest,
+// which main's fan-out of readReports' output calls in addition to (not
+// instead of) sending the report on to the InfluxDB writer, so a slow
+// HTTP client can never block ingestion.
+type Server struct {
+	cfg     config.HTTPConfig
+	sources map[string]StatusFunc
+
+	mu      sync.RWMutex
+	history []entry
+	latest  map[uint8]entry
+}
+
+// NewServer returns a Server that keeps the last cfg.BufferSize reports
+// (defaulting to 1000) and reports health for the given named sources.
+func NewServer(cfg config.HTTPConfig, sources map[string]StatusFunc) *Server {
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = 1000
+	}
+	return &Server{
+		cfg:     cfg,
+		sources: sources,
+		latest:  make(map[uint8]entry),
+	}
+}
+
+// Ingest records a report in the ring buffer and as the latest report for
+// its transmitter.  It is safe for concurrent use and never blocks.
+func (s *Server) Ingest(report wx.WxReport) {
+	e := entry{Report: report}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.latest[report.TransmitterID] = e
+
+	s.history = append(s.history, e)
+	if overflow := len(s.history) - s.cfg.BufferSize; overflow > 0 {
+		s.history = s.history[overflow:]
+	}
+}
+
+// ListenAndServe registers the API routes and blocks serving HTTP on
+// s.cfg.Address.
+func (s *Server) ListenAndServe() error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/current", s.handleCurrent)
+	mux.HandleFunc("/api/v1/history", s.handleHistory)
+	mux.HandleFunc("/api/v1/stations", s.handleStations)
+	mux.HandleFunc("/healthz", s.handleHealthz)
+
+	return http.ListenAndServe(s.cfg.Address, mux)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// handleCurrent returns the latest report per transmitter.
+func (s *Server) handleCurrent(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	current := make([]entry, 0, len(s.latest))
+	for _, e := range s.latest {
+		current = append(current, e)
+	}
+	sort.Slice(current, func(i, j int) bool {
+		return current[i].Report.TransmitterID < current[j].Report.TransmitterID
+	})
+
+	writeJSON(w, current)
+}
+
+// handleHistory returns buffered reports, optionally filtered by
+// ?since=<RFC3339 timestamp> and/or ?transmitter=<id>.
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	var since time.Time
+	if v := r.URL.Query().Get("since"); v != "" {
+		parsed, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "invalid since: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		since = parsed
+	}
+
+	var transmitter uint64
+	hasTransmitter := false
+	if v := r.URL.Query().Get("transmitter"); v != "" {
+		id, err := parseUint8(v)
+		if err != nil {
+			http.Error(w, "invalid transmitter: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		transmitter = uint64(id)
+		hasTransmitter = true
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	matched := make([]entry, 0, len(s.history))
+	for _, e := range s.history {
+		if !since.IsZero() && e.Report.Timestamp.Before(since) {
+			continue
+		}
+		if hasTransmitter && uint64(e.Report.TransmitterID) != transmitter {
+			continue
+		}
+		matched = append(matched, e)
+	}
+
+	writeJSON(w, matched)
+}
+
+// stationSummary describes a known transmitter and when it was last heard from.
+type stationSummary struct {
+	TransmitterID uint8     `json:"transmitter_id"`
+	LastSeen      time.Time `json:"last_seen"`
+}
+
+// handleStations returns every known transmitter ID and its last-seen time.
+func (s *Server) handleStations(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stations := make([]stationSummary, 0, len(s.latest))
+	for id, e := range s.latest {
+		stations = append(stations, stationSummary{TransmitterID: id, LastSeen: e.Report.Timestamp})
+	}
+	sort.Slice(stations, func(i, j int) bool {
+		return stations[i].TransmitterID < stations[j].TransmitterID
+	})
+
+	writeJSON(w, stations)
+}
+
+// handleHealthz reports the ConnStatus of each configured source.
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	status := make(map[string]string, len(s.sources))
+	healthy := true
+	for name, fn := range s.sources {
+		switch fn() {
+		case wx.Connected:
+			status[name] = "connected"
+		case wx.Connecting:
+			status[name] = "connecting"
+			healthy = false
+		default:
+			status[name] = "not_connected"
+			healthy = false
+		}
+	}
+
+	if !healthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	writeJSON(w, status)
+}
+
+func parseUint8(s string) (uint8, error) {
+	v, err := strconv.ParseUint(s, 10, 8)
+	if err != nil {
+		return 0, err
+	}
+	return uint8(v), nil
+}
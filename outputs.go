@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api"
+	influx "github.com/influxdb/influxdb/client/v2"
+
+	"github.com/chrissnell/wxinflux/config"
+	"github.com/chrissnell/wxinflux/wx"
+)
+
+// Outputs is implemented by every report-storage backend.  storeReports
+// writes to whichever Outputs the config selects, so new backends can be
+// added without touching the read side of the pipeline.
+type Outputs interface {
+	Write(ctx context.Context, reports []wx.WxReport) error
+	Close()
+}
+
+// NewOutputs builds the Outputs selected by cfg.Output (defaulting to the
+// classic InfluxDB 1.x client when unset).  The synchronous v1 backend is
+// wrapped in a bounded, batching queue so a slow or unreachable backend
+// never blocks storeReports; v2 already batches asynchronously itself and
+// is returned as-is.
+func NewOutputs(cfg config.Config) (Outputs, error) {
+	switch cfg.Output {
+	case "v2":
+		return newInfluxV2Output(cfg.InfluxDBv2, cfg.Stations)
+	case "v1", "":
+		out, err := newInfluxV1Output(cfg.InfluxDB, cfg.Stations)
+		if err != nil {
+			return nil, err
+		}
+		return newBatchedOutput(out, cfg.Queue), nil
+	default:
+		return nil, fmt.Errorf("unknown output backend %q", cfg.Output)
+	}
+}
+
+func wxReportPoint(report wx.WxReport, stationNames map[uint8]string) (map[string]string, map[string]interface{}) {
+	tags := map[string]string{
+		"transmitter-id": strconv.FormatUint(uint64(report.TransmitterID), 10),
+		"station_name":   stationNames[report.TransmitterID],
+		"stale_data":     strconv.FormatBool(report.StaleData),
+		"out_of_range":   strconv.FormatBool(report.OutOfRange),
+		"low_rssi":       strconv.FormatBool(report.LowRSSI),
+		"pressure_trend": report.PressureTrend,
+	}
+	fields := map[string]interface{}{
+		"wind_speed":         report.WindSpeed,
+		"wind_dir":           report.WindDir,
+		"wind_gust_10m":      report.WindGust10m,
+		"temperature":        report.Temperature,
+		"humidity":           report.Humidity,
+		"dewpoint":           report.Dewpoint,
+		"heat_index":         report.HeatIndex,
+		"wind_chill":         report.WindChill,
+		"uv_index":           report.UVIndex,
+		"solar_radiation":    report.SolarRadiation,
+		"rainfall":           report.Rainfall,
+		"rain_rate_1m":       report.RainRate1m,
+		"rain_rate_1h":       report.RainRate1h,
+		"rain_accum_day":     report.RainAccumDay,
+		"rain_accum_storm":   report.RainAccumStorm,
+		"thw_index":          report.THWIndex,
+		"thsw_index":         report.THSWIndex,
+		"wet_bulb":           report.WetBulb,
+		"et0":                report.ET0,
+		"sea_level_pressure": report.SeaLevelPressure,
+		"pressure_trend_3h":  report.PressureTrend3h,
+	}
+	return tags, fields
+}
+
+// influxV1Output writes reports using the InfluxDB 1.x HTTP client.
+type influxV1Output struct {
+	cfg          config.InfluxDBConfig
+	stationNames map[uint8]string
+	ic           influx.Client
+}
+
+func newInfluxV1Output(cfg config.InfluxDBConfig, stationNames map[uint8]string) (*influxV1Output, error) {
+	u, err := url.Parse(cfg.InfluxURL)
+	if err != nil {
+		return nil, err
+	}
+	ic := influx.NewClient(influx.Config{
+		URL:      u,
+		Username: cfg.InfluxUser,
+		Password: cfg.InfluxPass,
+	})
+	return &influxV1Output{cfg: cfg, stationNames: stationNames, ic: ic}, nil
+}
+
+func (o *influxV1Output) Write(ctx context.Context, reports []wx.WxReport) error {
+	bp, err := influx.NewBatchPoints(influx.BatchPointsConfig{
+		Database:  o.cfg.InfluxDBName,
+		Precision: "ns",
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, report := range reports {
+		tags, fields := wxReportPoint(report, o.stationNames)
+		pt := influx.NewPoint("wxreport", tags, fields, report.Timestamp)
+		bp.AddPoint(pt)
+	}
+
+	return o.ic.Write(bp)
+}
+
+func (o *influxV1Output) Close() {
+	o.ic.Close()
+}
+
+// influxV2Output writes reports using the InfluxDB 2.x/Flux client's
+// non-blocking write API.
+type influxV2Output struct {
+	cfg          config.InfluxDBv2Config
+	stationNames map[uint8]string
+	client       influxdb2.Client
+	writer       api.WriteAPI
+}
+
+func newInfluxV2Output(cfg config.InfluxDBv2Config, stationNames map[uint8]string) (*influxV2Output, error) {
+	client := influxdb2.NewClientWithOptions(cfg.URL, cfg.Token,
+		influxdb2.DefaultOptions().
+			SetBatchSize(cfg.BatchSize).
+			SetFlushInterval(uint(cfg.FlushIntervalMS)))
+
+	writer := client.WriteAPI(cfg.Org, cfg.Bucket)
+
+	o := &influxV2Output{cfg: cfg, stationNames: stationNames, client: client, writer: writer}
+
+	// The v2 client reports write errors asynchronously on a channel
+	// rather than from Write(), so we log them here instead.
+	go func() {
+		for err := range writer.Errors() {
+			log.Println("Error writing to InfluxDB v2:", err)
+		}
+	}()
+
+	return o, nil
+}
+
+func (o *influxV2Output) Write(ctx context.Context, reports []wx.WxReport) error {
+	for _, report := range reports {
+		tags, fields := wxReportPoint(report, o.stationNames)
+		p := influxdb2.NewPoint("wxreport", tags, fields, report.Timestamp)
+		o.writer.WritePoint(p)
+	}
+	return nil
+}
+
+func (o *influxV2Output) Close() {
+	o.writer.Flush()
+	o.client.Close()
+}
@@ -0,0 +1,200 @@
+// Package sources holds report sources (and sinks) that are alternatives
+// to, or companions of, the built-in Si1000 serial reader in the main
+// package.
+package sources
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/chrissnell/wxinflux/config"
+	"github.com/chrissnell/wxinflux/wx"
+)
+
+// initialReconnectDelay and maxReconnectDelay bound how long we'll wait
+// between reconnect attempts.
+const (
+	initialReconnectDelay = 1 * time.Second
+	maxReconnectDelay     = 60 * time.Second
+)
+
+// MQTTSource reads WxPacket frames off a broker topic and turns them into
+// WxReports, implementing the same readReports(chan<- wx.WxReport) contract
+// as DavisSi1000 so that multiple weather stations can feed one InfluxDB
+// instance over MQTT instead of (or alongside) a local serial device.
+type MQTTSource struct {
+	config   config.MQTTConfig
+	stations *wx.StationStates
+
+	client   mqtt.Client
+	clientMu sync.RWMutex
+	status   wx.ConnStatus
+	statusMu sync.RWMutex
+}
+
+// NewMQTTSource returns a new MQTTSource object.  stations is shared with
+// DavisSi1000 so that rain rates/accumulation, wind gust, pressure trend
+// and QC flags are tracked per-transmitter consistently no matter which
+// source a given transmitter's packets arrive through.
+func NewMQTTSource(cfg config.MQTTConfig, stations *wx.StationStates) *MQTTSource {
+	return &MQTTSource{config: cfg, stations: stations}
+}
+
+// Status returns the current connection status, for use by health checks.
+func (m *MQTTSource) Status() wx.ConnStatus {
+	m.statusMu.RLock()
+	defer m.statusMu.RUnlock()
+	return m.status
+}
+
+func (m *MQTTSource) setStatus(s wx.ConnStatus) {
+	m.statusMu.Lock()
+	m.status = s
+	m.statusMu.Unlock()
+}
+
+// Client returns the MQTTSource's current underlying client, or nil if it
+// hasn't connected yet.  This lets an MQTTSink publish over the same
+// connection instead of opening a second one to the same broker.
+func (m *MQTTSource) Client() mqtt.Client {
+	m.clientMu.RLock()
+	defer m.clientMu.RUnlock()
+	return m.client
+}
+
+// connect dials the broker, retrying with exponential backoff until it
+// succeeds.  On every (re)connect, including reconnects after an unplanned
+// disconnect, it (re)subscribes to m.config.SubscribeTopic.
+func (m *MQTTSource) connect(reportChan chan<- wx.WxReport) {
+	m.setStatus(wx.Connecting)
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(m.config.BrokerURL).
+		SetClientID(m.config.ClientID).
+		SetUsername(m.config.Username).
+		SetPassword(m.config.Password).
+		SetKeepAlive(time.Duration(m.config.KeepAliveSecs) * time.Second).
+		SetAutoReconnect(false). // we drive reconnection ourselves so we can resubscribe
+		SetConnectionLostHandler(func(c mqtt.Client, err error) {
+			log.Println("MQTT connection lost:", err)
+			m.setStatus(wx.NotConnected)
+			go m.connect(reportChan)
+		})
+
+	if m.config.TLS {
+		tlsCfg := &tls.Config{InsecureSkipVerify: m.config.TLSSkipVerify}
+		if m.config.CAFile != "" {
+			caCert, err := ioutil.ReadFile(m.config.CAFile)
+			if err != nil {
+				log.Println("Error reading MQTT ca_file:", err)
+			} else {
+				pool := x509.NewCertPool()
+				if pool.AppendCertsFromPEM(caCert) {
+					tlsCfg.RootCAs = pool
+				} else {
+					log.Println("Error parsing MQTT ca_file", m.config.CAFile, "as PEM")
+				}
+			}
+		}
+		opts.SetTLSConfig(tlsCfg)
+	}
+
+	delay := initialReconnectDelay
+	for {
+		client := mqtt.NewClient(opts)
+		m.clientMu.Lock()
+		m.client = client
+		m.clientMu.Unlock()
+
+		if token := client.Connect(); token.Wait() && token.Error() != nil {
+			log.Println("Error connecting to MQTT broker:", token.Error())
+			log.Printf("Sleeping %v and trying again\n", delay)
+			time.Sleep(delay)
+			if delay *= 2; delay > maxReconnectDelay {
+				delay = maxReconnectDelay
+			}
+			continue
+		}
+
+		if token := client.Subscribe(m.config.SubscribeTopic, m.config.QoS, m.handleMessage(reportChan)); token.Wait() && token.Error() != nil {
+			log.Println("Error subscribing to", m.config.SubscribeTopic, ":", token.Error())
+			client.Disconnect(250)
+			time.Sleep(delay)
+			continue
+		}
+
+		log.Println("Connected to MQTT broker", m.config.BrokerURL, "and subscribed to", m.config.SubscribeTopic)
+		m.setStatus(wx.Connected)
+		return
+	}
+}
+
+func (m *MQTTSource) handleMessage(reportChan chan<- wx.WxReport) mqtt.MessageHandler {
+	return func(c mqtt.Client, msg mqtt.Message) {
+		var packet wx.WxPacket
+		if err := json.Unmarshal(msg.Payload(), &packet); err != nil {
+			log.Println("Error decoding WxPacket from MQTT message:", err)
+			return
+		}
+		now := time.Now()
+		report := wx.GenerateWxReport(&packet)
+		report.Timestamp = now
+		m.stations.Enrich(&packet, &report, now)
+		reportChan <- report
+	}
+}
+
+// ReadReports connects to the configured broker and feeds derived WxReports
+// into reportChan until the process exits.
+func (m *MQTTSource) ReadReports(reportChan chan<- wx.WxReport) {
+	m.connect(reportChan)
+	select {}
+}
+
+// MQTTSink republishes each WxReport it receives from reportChan to
+// "<PublishTopicPrefix>/<transmitter-id>" as JSON, publishing over
+// whatever client clientFunc returns (typically MQTTSource.Client, so the
+// sink shares its connection with an MQTTSource on the same broker
+// instead of opening a second one).
+type MQTTSink struct {
+	config     config.MQTTConfig
+	clientFunc func() mqtt.Client
+}
+
+// NewMQTTSink returns a new MQTTSink that publishes over the client
+// returned by clientFunc.
+func NewMQTTSink(cfg config.MQTTConfig, clientFunc func() mqtt.Client) *MQTTSink {
+	return &MQTTSink{config: cfg, clientFunc: clientFunc}
+}
+
+// Publish republishes reports from reportChan until it is closed.  A
+// report is dropped (and logged) rather than blocking reportChan if the
+// underlying client isn't connected yet, e.g. during the source's initial
+// connect or a reconnect.
+func (s *MQTTSink) Publish(reportChan <-chan wx.WxReport) {
+	for report := range reportChan {
+		client := s.clientFunc()
+		if client == nil || !client.IsConnected() {
+			log.Printf("Dropping WxReport publish for transmitter %d: MQTT client not connected", report.TransmitterID)
+			continue
+		}
+
+		payload, err := json.Marshal(report)
+		if err != nil {
+			log.Println("Error marshaling WxReport for MQTT publish:", err)
+			continue
+		}
+		topic := fmt.Sprintf("%s/%d", s.config.PublishTopicPrefix, report.TransmitterID)
+		if token := client.Publish(topic, s.config.QoS, false, payload); token.Wait() && token.Error() != nil {
+			log.Println("Error publishing WxReport to", topic, ":", token.Error())
+		}
+	}
+}
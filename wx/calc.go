@@ -0,0 +1,184 @@
+package wx
+
+import (
+	"math"
+)
+
+// GenerateWxReport creates a human-usable weather report from a raw WxPacket.
+// It is the shared derivation step used by every source (serial, MQTT, ...)
+// so that they all produce identically-computed reports.
+func GenerateWxReport(p *WxPacket) WxReport {
+	r := WxReport{
+		TransmitterID:  p.TransmitterID,
+		WindSpeed:      p.WindSpeed,
+		WindDir:        p.WindDir,
+		Temperature:    p.Temperature,
+		Humidity:       p.Humidity,
+		Dewpoint:       dewpointFahrenheit(p.Temperature, p.Humidity),
+		HeatIndex:      heatIndexFahrenheit(p.Temperature, p.Humidity),
+		WindChill:      windchillFahrenheit(p.Temperature, float32(p.WindSpeed)),
+		UVIndex:        p.UVIndex,
+		SolarRadiation: p.SolarRadiation,
+		Rainfall:       float32(p.RainSpoons) * float32(0.1),
+	}
+	r.THWIndex = thwIndexFahrenheit(p.Temperature, p.Humidity, float32(p.WindSpeed))
+	r.THSWIndex = thswIndexFahrenheit(p.Temperature, p.Humidity, float32(p.WindSpeed), p.SolarRadiation)
+	r.WetBulb = wetBulbFahrenheit(p.Temperature, p.Humidity)
+	r.ET0 = et0MMPerDay(p.Temperature, p.Humidity, float32(p.WindSpeed), p.SolarRadiation)
+	return r
+}
+
+// thwIndexFahrenheit calculates the THW (Temperature-Humidity-Wind) index,
+// Davis Instruments' apparent temperature that layers a wind-chill-style
+// cooling correction onto the heat index, as also implemented by weewx.
+func thwIndexFahrenheit(t, rh, windMPH float32) float32 {
+	return heatIndexFahrenheit(t, rh) - 1.072*windMPH
+}
+
+// thswIndexFahrenheit calculates the THSW (Temperature-Humidity-Sun-Wind)
+// index, Davis Instruments' apparent temperature that adds vapor-pressure,
+// wind and solar-radiation terms to the heat index, as also implemented
+// by weewx.  It does not build on thwIndexFahrenheit: its wind term is
+// 0.7 per mph, not THW's 1.072.
+func thswIndexFahrenheit(t, rh, windMPH, solarWm2 float32) float32 {
+	tc := fToC(t)
+	vaporPressure := (rh / 100.0) * 6.105 * float32(math.Exp(float64(17.27*tc/(237.7+tc))))
+	return heatIndexFahrenheit(t, rh) + 0.348*vaporPressure - 0.7*windMPH + (0.7*solarWm2)/(windMPH+10) - 4.25
+}
+
+// wetBulbFahrenheit approximates the wet-bulb temperature using Stull's
+// (2011) formula, which is accurate to within about 1°C across typical
+// meteorological conditions without requiring a pressure term:
+// https://doi.org/10.1175/JAMC-D-11-0143.1
+func wetBulbFahrenheit(t, rh float32) float32 {
+	tc := float64(fToC(t))
+	rhf := float64(rh)
+
+	tw := tc*math.Atan(0.151977*math.Sqrt(rhf+8.313659)) +
+		math.Atan(tc+rhf) - math.Atan(rhf-1.676331) +
+		0.00391838*math.Pow(rhf, 1.5)*math.Atan(0.023101*rhf) -
+		4.686035
+
+	return cToF(float32(tw))
+}
+
+// et0MMPerDay estimates daily reference evapotranspiration (ET0), in
+// mm/day, via the FAO-56 Penman-Monteith equation:
+// http://www.fao.org/3/X0490E/x0490e06.htm (FAO Irrigation and Drainage
+// Paper 56, chapter 4, equation 6).  Net radiation is approximated from
+// solar radiation using the FAO reference-crop albedo of 0.23 and soil
+// heat flux is assumed to be negligible over a full day, since we don't
+// have separately-measured net radiation.  The psychrometric constant
+// assumes sea-level atmospheric pressure.
+func et0MMPerDay(t, rh, windMPH, solarWm2 float32) float32 {
+	tc := fToC(t)
+	windMS := windMPH * 0.44704
+
+	// Saturation and actual vapor pressure, in kPa.
+	es := 0.6108 * float32(math.Exp(float64(17.27*tc/(tc+237.3))))
+	ea := es * (rh / 100.0)
+
+	// Slope of the saturation vapor pressure curve, in kPa/°C.
+	delta := 4098 * es / float32(math.Pow(float64(tc+237.3), 2))
+
+	// Psychrometric constant at sea level, in kPa/°C.
+	gamma := float32(0.0665)
+
+	// Solar radiation, converted from an instantaneous W/m² reading to
+	// an assumed-average MJ/m²/day, then to net radiation.
+	rs := solarWm2 * 0.0864
+	rn := rs * (1 - 0.23)
+
+	numerator := 0.408*delta*rn + gamma*(900/(tc+273))*windMS*(es-ea)
+	denominator := delta + gamma*(1+0.34*windMS)
+
+	return numerator / denominator
+}
+
+func dewpointFahrenheit(t, rh float32) float32 {
+	return cToF(dewpointCelcius(fToC(t), rh))
+}
+
+// dewpointCelcius calculates the dewpoint in °C using the Magnus formula
+// per https://en.wikipedia.org/wiki/Dew_point#Calculating_the_dew_point
+func dewpointCelcius(t, rh float32) float32 {
+	if t < 0 {
+		return 0.0
+	}
+
+	// Prevent a divide-by-zero
+	if t == -237.7 {
+		return 0.0
+	}
+
+	rh = rh / 100.0
+	γ := 17.27*t/(237.7+t) + float32(math.Log(float64(rh)))
+
+	// Prevent a divide-by-zero
+	if γ == 17.27 {
+		return 0.0
+	}
+
+	TdpC := 237.7 * γ / (17.27 - γ)
+
+	return TdpC
+}
+
+// windchillFahrenheit calculates the wind chill using a calculation from
+// http://www.nws.noaa.gov/om/winter/windchill.shtml
+func windchillFahrenheit(t, ws float32) float32 {
+	// Wind chill is only valid for temps less than or equal to 50°F and wind speeds over 0 MPH.
+	if t >= 50 || ws <= 0 {
+		return t
+	}
+	WcF := 35.74 + 0.6215*t + (-35.75+0.4275*t)*float32(math.Pow(float64(ws), 0.16))
+	return WcF
+}
+
+// heatIndex calculates the heat index using the calculation from
+// http://www.wpc.ncep.noaa.gov/html/heatindex_equation.shtml
+func heatIndexFahrenheit(t, rh float32) float32 {
+	// Heat index is only valid for temps over 80°F and relative humidity over 40%
+	if t < 80.0 || rh <= 40.0 {
+		return t
+	}
+
+	heatIdx := -42.379 + 2.04901523*t + 10.14333127*rh - 0.22475541*t*rh - 6.83783e-3*t*t - 5.481717e-2*rh*rh + 1.22874e-3*t*t*rh + 8.5282e-4*t*rh*rh - 1.99e-6*t*t*rh*rh
+	return heatIdx
+}
+
+// SeaLevelPressureHPa reduces a station barometer reading (in inHg) to
+// sea-level pressure (in hPa) using the international barometric formula,
+// given the station's elevation in meters and the current temperature.
+func SeaLevelPressureHPa(stationInHg, tempF float32, elevationM float64) float32 {
+	stationHPa := stationInHg * 33.8639
+	tempK := float64(fToC(tempF)) + 273.15
+	factor := math.Pow(1-(0.0065*elevationM)/(tempK+0.0065*elevationM), -5.257)
+	return stationHPa * float32(factor)
+}
+
+// classifyPressureTrendThresholdHPa is the 3-hour delta, in hPa, above
+// (or below) which pressure is considered Rising (or Falling) rather
+// than Steady; roughly the NWS's "slowly rising/falling" threshold.
+const classifyPressureTrendThresholdHPa = 1.0
+
+// ClassifyPressureTrend labels a 3-hour sea-level pressure change as
+// Rising, Falling or Steady.
+func ClassifyPressureTrend(deltaHPa float32) string {
+	switch {
+	case deltaHPa >= classifyPressureTrendThresholdHPa:
+		return TrendRising
+	case deltaHPa <= -classifyPressureTrendThresholdHPa:
+		return TrendFalling
+	default:
+		return TrendSteady
+	}
+}
+
+func fToC(t float32) float32 {
+	return ((t - 32.0) * 5.0 / 9.0)
+}
+
+func cToF(t float32) float32 {
+	return (t * 9.0 / 5.0) + 32.0
+}
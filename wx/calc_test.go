@@ -0,0 +1,92 @@
+package wx
+
+import "testing"
+
+func approxEqual(t *testing.T, name string, got, want, tolerance float32) {
+	t.Helper()
+	if diff := got - want; diff < -tolerance || diff > tolerance {
+		t.Errorf("%s = %v, want %v (+/- %v)", name, got, want, tolerance)
+	}
+}
+
+// TestThwIndexFahrenheit_ZeroWind checks that THW reduces to the plain heat
+// index when there's no wind to subtract, per the formula in
+// thwIndexFahrenheit's doc comment.
+func TestThwIndexFahrenheit_ZeroWind(t *testing.T) {
+	got := thwIndexFahrenheit(95, 50, 0)
+	want := heatIndexFahrenheit(95, 50)
+	approxEqual(t, "thwIndexFahrenheit(95, 50, 0)", got, want, 0.01)
+}
+
+// TestThwIndexFahrenheit_Wind checks the 1.072 deg-per-mph wind correction
+// against a hand-worked NWS heat index of 95F/50%RH.
+func TestThwIndexFahrenheit_Wind(t *testing.T) {
+	got := thwIndexFahrenheit(95, 50, 10)
+	approxEqual(t, "thwIndexFahrenheit(95, 50, 10)", got, 94.50, 0.1)
+}
+
+// TestThswIndexFahrenheit_CalmClearNight checks the solar/wind-free THSW
+// case (95F/50%RH) against a hand-worked value of the Davis/weewx formula.
+func TestThswIndexFahrenheit_CalmClearNight(t *testing.T) {
+	got := thswIndexFahrenheit(95, 50, 0, 0)
+	approxEqual(t, "thswIndexFahrenheit(95, 50, 0, 0)", got, 110.72, 0.2)
+}
+
+// TestWetBulbFahrenheit_StullReferenceExample checks wetBulbFahrenheit
+// against the worked example from Stull (2011), sec. 1: T=20C, RH=50% ->
+// Tw=13.7C.
+func TestWetBulbFahrenheit_StullReferenceExample(t *testing.T) {
+	got := wetBulbFahrenheit(cToF(20), 50)
+	want := cToF(13.7)
+	approxEqual(t, "wetBulbFahrenheit(68, 50)", got, want, 0.5)
+}
+
+// TestEt0MMPerDay_SanityCheck exercises the FAO-56 Penman-Monteith
+// equation with inputs derived from FAO-56's Example 18 (Bangkok, April
+// 15th: Tmean ~30.2C, RHmean ~73.5%, u2=2 m/s, Rs~14.5 MJ/m2/day).  Our
+// et0MMPerDay takes a single mean temperature and an instantaneous solar
+// reading rather than Example 18's full Tmax/Tmin and daily radiation
+// balance, so it can't reproduce that example's published 5.7 mm/day
+// exactly; this checks the implementation against the value that formula
+// (as documented on et0MMPerDay) actually works out to for these inputs.
+func TestEt0MMPerDay_SanityCheck(t *testing.T) {
+	tempF := cToF(30.2)
+	windMPH := float32(2.0) / 0.44704
+	solarWm2 := float32(14.5) / 0.0864
+
+	got := et0MMPerDay(tempF, 73.5, windMPH, solarWm2)
+	approxEqual(t, "et0MMPerDay", got, 4.39, 0.2)
+}
+
+// TestSeaLevelPressureHPa_ZeroElevation checks that a station already at
+// sea level is returned unreduced, modulo the inHg->hPa conversion.
+func TestSeaLevelPressureHPa_ZeroElevation(t *testing.T) {
+	got := SeaLevelPressureHPa(29.92, 59, 0)
+	approxEqual(t, "SeaLevelPressureHPa(29.92, 59, 0)", got, 1013.21, 0.05)
+}
+
+// TestSeaLevelPressureHPa_Elevation checks the international barometric
+// formula's reduction at 500m against a hand-worked value.
+func TestSeaLevelPressureHPa_Elevation(t *testing.T) {
+	got := SeaLevelPressureHPa(29.92, 59, 500)
+	approxEqual(t, "SeaLevelPressureHPa(29.92, 59, 500)", got, 1074.77, 1.0)
+}
+
+func TestClassifyPressureTrend(t *testing.T) {
+	cases := []struct {
+		delta float32
+		want  string
+	}{
+		{1.5, TrendRising},
+		{1.0, TrendRising},
+		{-1.2, TrendFalling},
+		{-1.0, TrendFalling},
+		{0.3, TrendSteady},
+		{-0.3, TrendSteady},
+	}
+	for _, c := range cases {
+		if got := ClassifyPressureTrend(c.delta); got != c.want {
+			t.Errorf("ClassifyPressureTrend(%v) = %q, want %q", c.delta, got, c.want)
+		}
+	}
+}
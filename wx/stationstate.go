@@ -0,0 +1,250 @@
+package wx
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// stormRainResetAfter mirrors the Davis Vantage console's default
+	// "storm" rain reset: after this long with no rain, the storm total
+	// starts over on the next drop.
+	stormRainResetAfter = 24 * time.Hour
+
+	// staleDataAfter flags a report as stale if its transmitter hasn't
+	// been heard from in this long; the Si1000 bridge (and an MQTT
+	// source relaying the same packets) is expected to see each ISS
+	// every few seconds.
+	staleDataAfter = 30 * time.Second
+
+	// lowRSSIThreshold is the RSSI reading below which we consider the
+	// link to a transmitter marginal.
+	lowRSSIThreshold = 20
+
+	// badCRCRatioThreshold flags LowRSSI if bad-CRC packets make up more
+	// than this fraction of packets received from a transmitter.
+	badCRCRatioThreshold = 0.10
+
+	// lostPacketRatioThreshold flags LowRSSI if lost packets make up more
+	// than this fraction of packets expected from a transmitter.
+	lostPacketRatioThreshold = 0.10
+
+	// windHistoryWindow is how far back we keep wind samples for gust
+	// tracking, per the NWS convention of a 10-minute gust window.
+	windHistoryWindow = 10 * time.Minute
+)
+
+// windSample is one wind observation used for gust tracking.  Each
+// incoming WxPacket's WindSpeed stands in for a 3-second-average sample,
+// which is what the Si1000 bridge actually reports.
+type windSample struct {
+	at    time.Time
+	speed uint8
+}
+
+// rainSample is one rain accumulation event, in inches, used to compute
+// trailing-window rain rates.
+type rainSample struct {
+	at     time.Time
+	inches float32
+}
+
+// stationState tracks the per-transmitter history needed to turn a single
+// WxPacket into rates, accumulations and QC flags: rain spoon deltas,
+// recent wind samples for gust tracking, and the last-seen time.
+type stationState struct {
+	mu sync.Mutex
+
+	haveLastSpoons bool
+	lastSpoons     uint32
+	lastSeen       time.Time
+
+	rainHistory []rainSample
+	dayStart    time.Time
+	dayTotal    float32
+	stormTotal  float32
+	lastRainAt  time.Time
+
+	windHistory []windSample
+
+	pressureHistory []pressureSample
+}
+
+// pressureSample is one sea-level pressure observation, in hPa, used to
+// compute the 3-hour pressure trend.
+type pressureSample struct {
+	at  time.Time
+	hPa float32
+}
+
+// pressureTrendWindow is how far back we look to classify the pressure
+// trend, per the usual synoptic convention of a 3-hour trend.
+const pressureTrendWindow = 3 * time.Hour
+
+// StationStates holds one stationState per transmitter, created on first
+// sight of that transmitter.  It is the shared enrichment path between
+// every report source (serial, MQTT, ...), so a transmitter's rain
+// rates/accumulation, wind gust, pressure trend and QC flags are computed
+// consistently no matter which source last saw it.
+type StationStates struct {
+	mu         sync.Mutex
+	states     map[uint8]*stationState
+	elevationM float64
+}
+
+// NewStationStates returns a StationStates that reduces station pressure
+// to sea level assuming the given elevation, in meters.
+func NewStationStates(elevationM float64) *StationStates {
+	return &StationStates{states: make(map[uint8]*stationState), elevationM: elevationM}
+}
+
+func (s *StationStates) get(transmitterID uint8) *stationState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.states[transmitterID]
+	if !ok {
+		st = &stationState{}
+		s.states[transmitterID] = st
+	}
+	return st
+}
+
+// Enrich fills in the multi-packet derived fields (rain rates/accumulation,
+// gust, pressure trend, QC flags) on report, using and updating the
+// per-transmitter state for p.TransmitterID.
+func (s *StationStates) Enrich(p *WxPacket, report *WxReport, now time.Time) {
+	st := s.get(p.TransmitterID)
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	report.StaleData = st.haveLastSpoons && now.Sub(st.lastSeen) > staleDataAfter
+	st.lastSeen = now
+
+	st.applyRain(p, report, now)
+	st.applyGust(p, report, now)
+	st.applyPressure(p, report, now, s.elevationM)
+	applyQC(p, report)
+}
+
+// applyRain converts the raw spoon-tip counter delta (handling uint32
+// wrap-around) into an inch amount, folds it into the day and storm
+// totals, and computes trailing 1-minute/1-hour rain rates.
+func (st *stationState) applyRain(p *WxPacket, report *WxReport, now time.Time) {
+	const inchesPerSpoon = 0.1
+
+	var deltaSpoons uint32
+	if st.haveLastSpoons {
+		if p.RainSpoons >= st.lastSpoons {
+			deltaSpoons = p.RainSpoons - st.lastSpoons
+		} else {
+			// The counter wrapped around uint32's range.
+			deltaSpoons = (^uint32(0) - st.lastSpoons) + p.RainSpoons + 1
+		}
+	}
+	st.lastSpoons = p.RainSpoons
+	st.haveLastSpoons = true
+
+	deltaInches := float32(deltaSpoons) * inchesPerSpoon
+
+	if now.Sub(st.dayStart) >= 24*time.Hour || now.Day() != st.dayStart.Day() {
+		st.dayStart = now
+		st.dayTotal = 0
+	}
+	st.dayTotal += deltaInches
+
+	if deltaInches > 0 {
+		st.lastRainAt = now
+	} else if !st.lastRainAt.IsZero() && now.Sub(st.lastRainAt) >= stormRainResetAfter {
+		st.stormTotal = 0
+	}
+	st.stormTotal += deltaInches
+
+	st.rainHistory = append(st.rainHistory, rainSample{at: now, inches: deltaInches})
+	cutoff := now.Add(-1 * time.Hour)
+	trimmed := st.rainHistory[:0]
+	for _, sample := range st.rainHistory {
+		if sample.at.After(cutoff) {
+			trimmed = append(trimmed, sample)
+		}
+	}
+	st.rainHistory = trimmed
+
+	var last1m, last1h float32
+	oneMinuteAgo := now.Add(-1 * time.Minute)
+	for _, sample := range st.rainHistory {
+		last1h += sample.inches
+		if sample.at.After(oneMinuteAgo) {
+			last1m += sample.inches
+		}
+	}
+
+	report.Rainfall = deltaInches
+	report.RainRate1m = last1m * 60
+	report.RainRate1h = last1h
+	report.RainAccumDay = st.dayTotal
+	report.RainAccumStorm = st.stormTotal
+}
+
+// applyGust keeps a rolling 10-minute window of wind samples and reports
+// the peak as WindGust10m, per the NWS convention that a gust is the
+// highest 3-second average observed in the preceding 10 minutes.
+func (st *stationState) applyGust(p *WxPacket, report *WxReport, now time.Time) {
+	st.windHistory = append(st.windHistory, windSample{at: now, speed: p.WindSpeed})
+
+	cutoff := now.Add(-windHistoryWindow)
+	trimmed := st.windHistory[:0]
+	var gust uint8
+	for _, sample := range st.windHistory {
+		if sample.at.After(cutoff) {
+			trimmed = append(trimmed, sample)
+			if sample.speed > gust {
+				gust = sample.speed
+			}
+		}
+	}
+	st.windHistory = trimmed
+
+	report.WindGust10m = gust
+}
+
+// applyPressure reduces the packet's station pressure to sea level,
+// records it, and classifies the trend over the trailing 3 hours.
+func (st *stationState) applyPressure(p *WxPacket, report *WxReport, now time.Time, elevationM float64) {
+	slp := SeaLevelPressureHPa(p.Barometer, p.Temperature, elevationM)
+	report.SeaLevelPressure = slp
+
+	st.pressureHistory = append(st.pressureHistory, pressureSample{at: now, hPa: slp})
+	cutoff := now.Add(-pressureTrendWindow)
+	trimmed := st.pressureHistory[:0]
+	for _, sample := range st.pressureHistory {
+		if sample.at.After(cutoff) {
+			trimmed = append(trimmed, sample)
+		}
+	}
+	st.pressureHistory = trimmed
+
+	if len(st.pressureHistory) == 0 {
+		return
+	}
+	delta := slp - st.pressureHistory[0].hPa
+	report.PressureTrend3h = delta
+	report.PressureTrend = ClassifyPressureTrend(delta)
+}
+
+// applyQC sets OutOfRange and LowRSSI from the raw packet's fields.
+func applyQC(p *WxPacket, report *WxReport) {
+	report.OutOfRange = p.Temperature < -80 || p.Temperature > 140 ||
+		p.Humidity < 0 || p.Humidity > 100
+
+	lowRSSI := p.RSSI < lowRSSIThreshold
+	if p.RxPackets > 0 {
+		badCRCRatio := float64(p.BadCRCPackets) / float64(p.RxPackets)
+		lowRSSI = lowRSSI || badCRCRatio > badCRCRatioThreshold
+	}
+	if expected := p.RxPackets + p.LostPackets; expected > 0 {
+		lostRatio := float64(p.LostPackets) / float64(expected)
+		lowRSSI = lowRSSI || lostRatio > lostPacketRatioThreshold
+	}
+	report.LowRSSI = lowRSSI
+}
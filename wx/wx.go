@@ -0,0 +1,102 @@
+// Package wx holds the weather domain types shared by every report source
+// (serial, MQTT, ...) and every report sink (InfluxDB, HTTP API, ...).
+package wx
+
+import "time"
+
+// ConnStatus is used to indicate the status of a source's connection to its
+// upstream (serial device, MQTT broker, etc.)
+type ConnStatus int
+
+const (
+	NotConnected ConnStatus = iota
+	Connecting
+	Connected
+)
+
+// WxPacket defines packets, as we receive them from the Si1000 ISS receiver
+// or, verbatim, over MQTT.
+type WxPacket struct {
+	Ready          bool    `json:"ready"`
+	Status         string  `json:"status"`
+	TransmitterID  uint8   `json:"transmitter_id,omitempty"`
+	RSSI           uint16  `json:"RSSI,omitempty"`
+	RxPackets      uint16  `json:"recv_packets,omitempty"`
+	LostPackets    uint16  `json:"lost_packets,omitempty"`
+	BadCRCPackets  uint16  `json:"bad_CRC,omitempty"`
+	WindSpeed      uint8   `json:"wind_speed_mph,omitempty"`
+	WindDir        uint16  `json:"wind_direction_degrees,omitempty"`
+	Temperature    float32 `json:"temperature_F,omitempty"`
+	Humidity       float32 `json:"humidity_pct,omitempty"`
+	UVIndex        float32 `json:"UV_index,omitempty"`
+	SolarRadiation float32 `json:"solar_Wm2,omitempty"`
+	RainSpoons     uint32  `json:"rain_spoons,omitempty"`
+	Barometer      float32 `json:"barometer_inHg,omitempty"`
+	Raw            string  `json:"raw,omitempty"`
+	Version        string  `json:"version,omitempty"`
+}
+
+// WxReport holds a weather report, derived from a WxPacket.
+type WxReport struct {
+	// Timestamp is when the source decoded the underlying WxPacket, not
+	// when the report was eventually written to an output backend, so
+	// that batched or replayed writes preserve the original ordering.
+	Timestamp time.Time
+
+	TransmitterID  uint8
+	WindSpeed      uint8
+	WindDir        uint16
+	Temperature    float32
+	Humidity       float32
+	Dewpoint       float32
+	HeatIndex      float32
+	WindChill      float32
+	UVIndex        float32
+	SolarRadiation float32
+	Rainfall       float32
+
+	// RainRate1m and RainRate1h are rain rates, in in/hr, extrapolated
+	// from the rain accumulated in the trailing 1 minute and 1 hour
+	// respectively.  RainAccumDay resets at local midnight;
+	// RainAccumStorm resets after a fixed 24-hour dry spell.  All four
+	// are computed per-transmitter by StationStates.Enrich, not here,
+	// since they depend on state from prior packets.
+	RainRate1m     float32
+	RainRate1h     float32
+	RainAccumDay   float32
+	RainAccumStorm float32
+
+	// WindGust10m is the peak 3-second-average wind speed observed in
+	// the trailing 10 minutes (the NWS convention for "wind gust"),
+	// tracked per-transmitter by StationStates.Enrich.
+	WindGust10m uint8
+
+	// QC flags, set by StationStates.Enrich from the raw WxPacket's
+	// link-quality counters and from the report's own field values.
+	StaleData  bool
+	OutOfRange bool
+	LowRSSI    bool
+
+	// THWIndex, THSWIndex, WetBulb and ET0 are additional derived
+	// meteorology, computed directly from this packet.
+	THWIndex  float32
+	THSWIndex float32
+	WetBulb   float32
+	ET0       float32
+
+	// SeaLevelPressure and the PressureTrend* fields are computed by
+	// StationStates.Enrich from a short per-transmitter pressure history,
+	// since a trend can't be determined from a single packet. This runs
+	// for every source (serial and MQTT alike), so it's populated
+	// regardless of which one last saw the transmitter.
+	SeaLevelPressure float32
+	PressureTrend    string
+	PressureTrend3h  float32
+}
+
+// Pressure trend classifications, per PressureTrend above.
+const (
+	TrendRising  = "Rising"
+	TrendFalling = "Falling"
+	TrendSteady  = "Steady"
+)
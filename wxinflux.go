@@ -1,71 +1,31 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"io"
 	"log"
-	"net/url"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	influx "github.com/influxdb/influxdb/client/v2"
 	"github.com/tarm/goserial"
 
 	"github.com/chrissnell/wxinflux/config"
+	"github.com/chrissnell/wxinflux/httpapi"
+	"github.com/chrissnell/wxinflux/sources"
+	"github.com/chrissnell/wxinflux/wx"
 )
 
-// ConnStatus is used to indicate the status of the serial connection
-type ConnStatus int
-
-const (
-	NotConnected ConnStatus = iota
-	Connecting
-	Connected
-)
-
-// WxPacket defines packets, as we receive them from the Si1000 ISS receiver
-type WxPacket struct {
-	Ready          bool    `json:"ready"`
-	Status         string  `json:"status"`
-	TransmitterID  uint8   `json:"transmitter_id,omitempty"`
-	RSSI           uint16  `json:"RSSI,omitempty"`
-	RxPackets      uint16  `json:"recv_packets,omitempty"`
-	LostPackets    uint16  `json:"lost_packets,omitempty"`
-	BadCRCPackets  uint16  `json:"bad_CRC,omitempty"`
-	WindSpeed      uint8   `json:"wind_speed_mph,omitempty"`
-	WindDir        uint16  `json:"wind_direction_degrees,omitempty"`
-	Temperature    float32 `json:"temperature_F,omitempty"`
-	Humidity       float32 `json:"humidity_pct,omitempty"`
-	UVIndex        float32 `json:"UV_index,omitempty"`
-	SolarRadiation float32 `json:"solar_Wm2,omitempty"`
-	RainSpoons     uint32  `json:"rain_spoons,omitempty"`
-	Raw            string  `json:"raw,omitempty"`
-	Version        string  `json:"version,omitempty"`
-}
-
-// WxReport holds a weather report, derived from a WxPacket.
-type WxReport struct {
-	TransmitterID  uint8
-	WindSpeed      uint8
-	WindDir        uint16
-	Temperature    float32
-	Humidity       float32
-	Dewpoint       float32
-	HeatIndex      float32
-	WindChill      float32
-	UVIndex        float32
-	SolarRadiation float32
-	Rainfall       float32
-}
-
 // DavisSi1000 hold our connection to the Si1000-based USB ISS receiver
 type DavisSi1000 struct {
 	config   config.Config
 	conn     io.ReadWriteCloser
-	status   ConnStatus
+	status   wx.ConnStatus
 	statusMu sync.RWMutex
+	stations *wx.StationStates
 }
 
 // NewDavisSi1000 returns a new DavisSi1000 object
@@ -73,20 +33,27 @@ func NewDavisSi1000() *DavisSi1000 {
 	return &DavisSi1000{}
 }
 
+// Status returns the current connection status, for use by health checks.
+func (d *DavisSi1000) Status() wx.ConnStatus {
+	d.statusMu.RLock()
+	defer d.statusMu.RUnlock()
+	return d.status
+}
+
 func (d *DavisSi1000) connectToSerialSi1000() {
 	var err error
 
 	d.statusMu.RLock()
 
 	switch d.status {
-	case Connecting:
+	case wx.Connecting:
 		d.statusMu.RUnlock()
 		log.Println("Skipping reconnect since connection is in progress")
 		return
-	case NotConnected:
+	case wx.NotConnected:
 		d.statusMu.RUnlock()
 		d.statusMu.Lock()
-		d.status = Connecting
+		d.status = wx.Connecting
 		d.statusMu.Unlock()
 		log.Println("Connecting to local Si1000 on", d.config.Si2000.Device, "...")
 		for {
@@ -97,7 +64,7 @@ func (d *DavisSi1000) connectToSerialSi1000() {
 			} else {
 				// We connected.
 				d.statusMu.Lock()
-				d.status = Connected
+				d.status = wx.Connected
 				d.statusMu.Unlock()
 				log.Println("Connection to local Si1000 on", d.config.Si2000.Device, "successful.")
 				return
@@ -107,90 +74,49 @@ func (d *DavisSi1000) connectToSerialSi1000() {
 }
 
 // ReadReports reads wx reports from the Si1000 and sends them off for processing
-func (d *DavisSi1000) readReports(reportChan chan<- WxReport) {
+func (d *DavisSi1000) readReports(reportChan chan<- wx.WxReport) {
 	for {
 		// We recreate a json.Decoder with each loop because the connection may have dropped
 		// and if it has, we'll need a fresh Decoder over that new net.Conn
 		dec := json.NewDecoder(d.conn)
 
 		for {
-			var packet WxPacket
+			var packet wx.WxPacket
 			if err := dec.Decode(&packet); err == io.EOF {
 				log.Println("Error reading from device:", err)
 				d.statusMu.Lock()
-				d.status = NotConnected
+				d.status = wx.NotConnected
 				d.statusMu.Unlock()
 				d.connectToSerialSi1000()
 				break
 			}
-			report := generateWxReport(&packet)
+			now := time.Now()
+			report := wx.GenerateWxReport(&packet)
+			report.Timestamp = now
+			d.stations.Enrich(&packet, &report, now)
 			reportChan <- report
 		}
 	}
 }
 
-func (d *DavisSi1000) storeReports(reportChan <-chan WxReport, ic influx.Client) {
-	for {
-		select {
-		case report := <-reportChan:
-			bp, err := influx.NewBatchPoints(influx.BatchPointsConfig{
-				Database:  d.config.InfluxDB.InfluxDBName,
-				Precision: "s",
-			})
-			if err != nil {
-				log.Println("Error logging report to InfluxDB:", err)
-				continue
-			}
-			tags := map[string]string{"transmitter-id": string(report.TransmitterID)}
-			fields := map[string]interface{}{
-				"wind_speed":      report.WindSpeed,
-				"wind_dir":        report.WindDir,
-				"temperature":     report.Temperature,
-				"humidity":        report.Humidity,
-				"dewpoint":        report.Dewpoint,
-				"heat_index":      report.HeatIndex,
-				"wind_chill":      report.WindChill,
-				"uv_index":        report.UVIndex,
-				"solar_radiation": report.SolarRadiation,
-				"rainfall":        report.Rainfall,
-			}
-
-			pt := influx.NewPoint("wxreport", tags, fields, time.Now())
-			bp.AddPoint(pt)
-			err = ic.Write(bp)
-			if err != nil {
-				log.Println("Error logging data point to InfluxDB:", err)
-				continue
-			}
-			log.Printf("Received report: %+v\n", report)
-
+// storeReports writes each incoming report to out.  The backend (v1, v2, ...)
+// is selected by config and handed in by main.
+func (d *DavisSi1000) storeReports(reportChan <-chan wx.WxReport, out Outputs) {
+	for report := range reportChan {
+		if err := out.Write(context.Background(), []wx.WxReport{report}); err != nil {
+			log.Println("Error writing report to output backend:", err)
+			continue
 		}
+		log.Printf("Received report: %+v\n", report)
 	}
 }
 
-// generateWxReport creates a human-usable weather report from the raw WxPacket
-func generateWxReport(p *WxPacket) WxReport {
-	r := WxReport{
-		TransmitterID:  p.TransmitterID,
-		WindSpeed:      p.WindSpeed,
-		WindDir:        p.WindDir,
-		Temperature:    p.Temperature,
-		Humidity:       p.Humidity,
-		Dewpoint:       dewpointFahrenheit(p.Temperature, p.Humidity),
-		HeatIndex:      heatIndexFahrenheit(p.Temperature, p.Humidity),
-		WindChill:      windchillFahrenheit(p.Temperature, float32(p.WindSpeed)),
-		UVIndex:        p.UVIndex,
-		SolarRadiation: p.SolarRadiation,
-		Rainfall:       float32(p.RainSpoons) * float32(0.1),
-	}
-	return r
-}
-
 func main() {
 	cfgFile := flag.String("config", "config.yaml", "Path to config file (default: ./config.yaml)")
 	flag.Parse()
 
-	reportChan := make(chan WxReport)
+	rawChan := make(chan wx.WxReport)
+	storeChan := make(chan wx.WxReport)
 
 	d := NewDavisSi1000()
 
@@ -201,16 +127,68 @@ func main() {
 		log.Fatalln("Error reading config file.  Did you pass the -config flag?  Run with -h for help.\n", err)
 	}
 	d.config = cfg
+	stations := wx.NewStationStates(cfg.StationElevationM)
+	d.stations = stations
+
+	out, err := NewOutputs(cfg)
+	if err != nil {
+		log.Fatalln("Error configuring output backend:", err)
+	}
+	defer out.Close()
+
+	var m *sources.MQTTSource
+	var mqttPublish chan wx.WxReport
+	sourceStatus := map[string]httpapi.StatusFunc{"si1000": d.Status}
+	if cfg.MQTT.Enabled {
+		m = sources.NewMQTTSource(cfg.MQTT, stations)
+		sourceStatus["mqtt"] = m.Status
+
+		if cfg.MQTT.PublishTopicPrefix != "" {
+			sink := sources.NewMQTTSink(cfg.MQTT, m.Client)
+			mqttPublish = make(chan wx.WxReport)
+			go sink.Publish(mqttPublish)
+		}
+	}
+
+	var httpSrv *httpapi.Server
+	if cfg.HTTP.Enabled {
+		httpSrv = httpapi.NewServer(cfg.HTTP, sourceStatus)
+		go func() {
+			log.Println("Starting HTTP API on", cfg.HTTP.Address)
+			log.Println(httpSrv.ListenAndServe())
+		}()
+	}
+
+	// Fan rawChan out to the (fast, in-memory) HTTP ring buffer, the
+	// optional MQTT republish sink, and on to storeChan for the
+	// (potentially slow) InfluxDB writer, so a slow backend can never
+	// hold up the HTTP API or MQTT publishing and vice versa.
+	var mqttPublishDropped uint64
+	go func() {
+		for report := range rawChan {
+			if httpSrv != nil {
+				httpSrv.Ingest(report)
+			}
+			if mqttPublish != nil {
+				select {
+				case mqttPublish <- report:
+				default:
+					dropped := atomic.AddUint64(&mqttPublishDropped, 1)
+					log.Printf("MQTT publish queue full; dropped report (transmitter %d). Total dropped: %d", report.TransmitterID, dropped)
+				}
+			}
+			storeChan <- report
+		}
+	}()
 
-	// Connect to influxdb
-	u, _ := url.Parse(d.config.InfluxDB.InfluxURL)
-	ic := influx.NewClient(influx.Config{
-		URL:      u,
-		Username: d.config.InfluxDB.InfluxUser,
-		Password: d.config.InfluxDB.InfluxPass,
-	})
+	go d.storeReports(storeChan, out)
 
+	// The local Si1000 serial device is always read from.  MQTT is an
+	// additional, optional source so that remote stations that publish
+	// WxPackets to a broker can feed this same InfluxDB instance.
 	d.connectToSerialSi1000()
-	go d.storeReports(reportChan, ic)
-	d.readReports(reportChan)
+	if m != nil {
+		go m.ReadReports(rawChan)
+	}
+	d.readReports(rawChan)
 }